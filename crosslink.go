@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/google/go-github/github"
+)
+
+// RepositoryConfig identifies a GitHub repository by owner(s) and name, the
+// same shape as BaseConfig.Repository. BaseConfig.ExtraRepositories lists
+// other repositories glyph is also building into this outDir, so issue
+// links between them can be rewritten to the local generated page instead of
+// staying external.
+type RepositoryConfig struct {
+	Users []string
+	Name  string
+
+	// Labels is the full set of label names that exist on the repository,
+	// independent of whether any exported issue currently carries them.
+	// loadIssueTemplates validates issue template front matter against
+	// this list rather than against labels merely observed on exported
+	// issues, so a brand-new label unused by any post still survives.
+	Labels []string
+
+	// Issues are this repository's already-fetched GitHub issues, supplied
+	// by the caller the same way BuildSite's own issues parameter is for
+	// the primary repository. An ExtraRepositories entry without Issues
+	// only resolves cross-repo links if that repository happens to have
+	// already been registered earlier in this same process (see
+	// registerRepoLinks); setting Issues lets a single-repo build resolve
+	// links into it regardless.
+	Issues []*github.Issue
+}
+
+// repoRef is the lookup key and link prefix for one repository being
+// considered while rewriting cross-repo links.
+type repoRef struct {
+	key    string // "owner/name", used against crossRepoIndex
+	prefix string // "https://github.com/owner/name/issues/"
+}
+
+func newRepoRef(users []string, name string) repoRef {
+	if len(users) == 0 {
+		return repoRef{}
+	}
+	return repoRef{
+		key:    users[0] + "/" + name,
+		prefix: fmt.Sprintf("https://github.com/%s/%s/issues/", users[0], name),
+	}
+}
+
+// crossRepoIndex maps a repository's "owner/repo" key to its issues by
+// number. It's populated two ways: as each repository built in this process
+// is prepared (registerRepoLinks), and for an ExtraRepositories entry that
+// carries its own pre-fetched Issues (registerExtraRepoLinks) -- the latter
+// is what makes a single-repo build able to resolve links into a repository
+// it isn't itself building.
+var crossRepoIndex = map[string]map[int]Issue{}
+
+func registerRepoLinks(ref repoRef, export []Issue) {
+	if ref.key == "" {
+		return
+	}
+	byNumber := make(map[int]Issue, len(export))
+	for _, issue := range export {
+		byNumber[issue.Number] = issue
+	}
+	crossRepoIndex[ref.key] = byNumber
+}
+
+// registerExtraRepoLinks builds a minimal link index -- just enough for
+// rewriteCrossRepoLinks to resolve an href -- from an ExtraRepositories
+// entry's pre-fetched issues, without rendering their content or applying
+// this repo's own export rules (e.g. the "draft" label skip).
+func registerExtraRepoLinks(ref repoRef, issues []*github.Issue) {
+	if ref.key == "" || len(issues) == 0 {
+		return
+	}
+	byNumber := make(map[int]Issue, len(issues))
+	for _, issue := range issues {
+		title := issue.GetTitle()
+		if title == "" {
+			continue
+		}
+		byNumber[issue.GetNumber()] = Issue{
+			Number: issue.GetNumber(),
+			Title:  title,
+			Link:   issueLink(issue.GetNumber(), title),
+		}
+	}
+	crossRepoIndex[ref.key] = byNumber
+}
+
+// rewriteCrossRepoLinks rewrites every <a href> in doc that points at an
+// issue in thisRepo or one of extraRepos to that issue's generated page,
+// when the target repository's issues are known (i.e. also being built into
+// this outDir). Links to issues glyph isn't exporting, and links to any
+// other repository entirely, are left untouched. #issuecomment-* anchors are
+// dropped, since they only make sense on GitHub's own issue page. Backlinks
+// discovered for an issue in thisRepo are recorded into backlinks, keyed by
+// the referenced issue's number.
+func rewriteCrossRepoLinks(doc *goquery.Document, thisRepo repoRef, extraRepos []repoRef, localIssues map[int]Issue, referrer Issue, backlinks map[int][]Issue) {
+	repos := append([]repoRef{thisRepo}, extraRepos...)
+
+	doc.Find("body a").Each(func(_ int, item *goquery.Selection) {
+		href, ok := item.Attr("href")
+		if !ok {
+			return
+		}
+
+		for _, repo := range repos {
+			if repo.prefix == "" || !strings.HasPrefix(href, repo.prefix) {
+				continue
+			}
+
+			rest := strings.TrimPrefix(href, repo.prefix)
+			rest = strings.TrimPrefix(rest, "/")
+			numPart, anchor, hasAnchor := strings.Cut(rest, "#")
+			numPart = strings.Trim(numPart, " /")
+
+			issueNum, err := strconv.Atoi(numPart)
+			if err != nil {
+				return
+			}
+			if hasAnchor && strings.HasPrefix(anchor, "issuecomment-") {
+				anchor = ""
+			}
+
+			var target Issue
+			var found bool
+			if repo.key == thisRepo.key {
+				target, found = localIssues[issueNum]
+			} else {
+				target, found = crossRepoIndex[repo.key][issueNum]
+			}
+			if !found {
+				// Not one of the issues we're exporting -- leave the link
+				// pointing at GitHub.
+				return
+			}
+
+			link := target.Link
+			if anchor != "" {
+				link += "#" + anchor
+			}
+			item.SetAttr("href", link)
+
+			if repo.key == thisRepo.key {
+				backlinks[issueNum] = append(backlinks[issueNum], referrer)
+			}
+			return
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"gopkg.in/yaml.v2"
+)
+
+// draftFrontMatter is the YAML metadata block at the top of a drafts/*.md
+// file.
+type draftFrontMatter struct {
+	Title   string    `yaml:"title"`
+	Labels  []string  `yaml:"labels"`
+	Created time.Time `yaml:"created"`
+}
+
+// splitFrontMatter separates a leading YAML front-matter block from the rest
+// of the document. It tolerates both the common "---" fence and the "-----"
+// fence some editors insert, and reports ok=false when src has no front
+// matter at all, in which case body is the whole of src.
+func splitFrontMatter(src []byte) (meta []byte, body []byte, ok bool) {
+	lines := strings.Split(string(src), "\n")
+	if len(lines) == 0 || !isFrontMatterFence(strings.TrimSpace(lines[0])) {
+		return nil, src, false
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if isFrontMatterFence(strings.TrimSpace(lines[i])) {
+			return []byte(strings.Join(lines[1:i], "\n")), []byte(strings.Join(lines[i+1:], "\n")), true
+		}
+	}
+
+	return nil, src, false
+}
+
+func isFrontMatterFence(line string) bool {
+	return line == "---" || line == "-----"
+}
+
+// loadDraftIssues turns every Markdown file in dir into a synthetic Issue, so
+// `glyph serve` can preview unpublished posts without pushing them to GitHub
+// first. A missing directory is not an error: not every repo keeps local
+// drafts.
+func loadDraftIssues(dir string, renderer Renderer) ([]Issue, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	drafts := []Issue{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
+			continue
+		}
+
+		raw, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		meta, body, ok := splitFrontMatter(raw)
+		fm := draftFrontMatter{}
+		if ok {
+			if err := yaml.Unmarshal(meta, &fm); err != nil {
+				return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+		}
+		if fm.Title == "" {
+			fm.Title = strings.TrimSuffix(entry.Name(), ".md")
+		}
+		if fm.Created.IsZero() {
+			fm.Created = time.Now()
+		}
+
+		labels := make([]Label, 0, len(fm.Labels))
+		for _, name := range fm.Labels {
+			labels = append(labels, Label{Name: name, Link: "label-" + name + ".html"})
+		}
+
+		rendered, err := renderer.Render(body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		drafts = append(drafts, Issue{
+			Number:  -1,
+			Title:   fm.Title,
+			Link:    fmt.Sprintf("draft-%s.html", slug.Make(fm.Title)),
+			Content: string(rendered),
+			Labels:  labels,
+			Created: fm.Created,
+			Updated: fm.Created,
+		})
+	}
+
+	sort.Slice(drafts, func(i, j int) bool { return drafts[i].Created.After(drafts[j].Created) })
+	return drafts, nil
+}
@@ -4,17 +4,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/cbroglie/mustache"
 	"github.com/google/go-github/github"
-	"github.com/gorilla/feeds"
 	"github.com/gosimple/slug"
-
-	gfm "github.com/shurcooL/github_flavored_markdown"
 )
 
 type Label struct {
@@ -31,54 +27,38 @@ type Issue struct {
 	Labels     []Label
 	GithubLink string
 	Created    time.Time
+	Updated    time.Time
+	Backlinks  []Issue // other issues in this repo whose body links to this one
 }
 
-func exportFeed(issues []Issue) error {
-	now := time.Now()
-	feed := &feeds.Feed{
-		Title:       baseCfg.Site.Title,
-		Link:        &feeds.Link{Href: fmt.Sprintf("https://%s.github.io/%s", baseCfg.Repository.Users[0], baseCfg.Repository.Name)},
-		Description: baseCfg.Site.OneLineDesc,
-		Author:      &feeds.Author{Name: baseCfg.Site.Author, Email: baseCfg.Site.Mail},
-		Created:     now,
-	}
-	feed.Items = []*feeds.Item{}
-
-	for _, issue := range issues {
-		item := &feeds.Item{
-			Title:       issue.Title,
-			Link:        &feeds.Link{Href: fmt.Sprintf("https://%s.github.io/%s/%s", baseCfg.Repository.Users[0], baseCfg.Repository.Name, issue.Link)},
-			Description: issue.Summary,
-			Author:      &feeds.Author{Name: baseCfg.Site.Author, Email: baseCfg.Site.Mail},
-			Created:     issue.Created,
-		}
-		feed.Items = append(feed.Items, item)
-	}
-
-	atom, err := feed.ToAtom()
-	if err != nil {
-		return err
-	}
-
-	return ioutil.WriteFile(filepath.Join(outDir, feedFile), []byte(atom), 0755)
+// issueLink builds the generated page filename for an issue with the given
+// number and title. Shared by prepareIssues and registerExtraRepoLinks so
+// same-repo and cross-repo (ExtraRepositories.Issues) links agree on the
+// same naming scheme.
+func issueLink(number int, title string) string {
+	return fmt.Sprintf("%d-%s.html", number, slug.Make(title))
 }
 
-func prepareIssues(issues []*github.Issue, baseCfg BaseConfig) ([]Issue, error) {
+func prepareIssues(issues []*github.Issue, baseCfg BaseConfig, renderer Renderer) ([]Issue, []IssueTemplate, error) {
 	export := []Issue{}
 
 	for _, issue := range issues {
 		exIssue := Issue{
 			Title:      issue.GetTitle(),
-			Link:       fmt.Sprintf("%d-%s.html", issue.GetNumber(), slug.Make(issue.GetTitle())),
+			Link:       issueLink(issue.GetNumber(), issue.GetTitle()),
 			Created:    issue.GetCreatedAt(),
+			Updated:    issue.GetUpdatedAt(),
 			Labels:     []Label{},
 			GithubLink: issue.GetHTMLURL(),
 			Number:     issue.GetNumber(),
 		}
 
-		// TODO maybe add syntax highlighting with chroma here?
 		if exIssue.Title != "" {
-			exIssue.Content = string(gfm.Markdown([]byte(issue.GetBody())))
+			rendered, err := renderer.Render([]byte(issue.GetBody()))
+			if err != nil {
+				return nil, nil, fmt.Errorf("rendering issue #%d: %w", exIssue.Number, err)
+			}
+			exIssue.Content = string(rendered)
 			doc, err := goquery.NewDocumentFromReader(strings.NewReader(exIssue.Content))
 			if err == nil {
 				// Use first paragraph(p) as summary.
@@ -108,32 +88,27 @@ func prepareIssues(issues []*github.Issue, baseCfg BaseConfig) ([]Issue, error)
 		// We ignore issues with empty titles.
 	}
 
-	thisRepoURL := "https://github.com/" + baseCfg.Repository.Users[0] + "/" + baseCfg.Repository.Name + "/issues/"
-	// Post processing loop over issues.
+	thisRepo := newRepoRef(baseCfg.Repository.Users, baseCfg.Repository.Name)
+	extraRepos := make([]repoRef, len(baseCfg.ExtraRepositories))
+	for i, r := range baseCfg.ExtraRepositories {
+		extraRepos[i] = newRepoRef(r.Users, r.Name)
+		registerExtraRepoLinks(extraRepos[i], r.Issues)
+	}
+	registerRepoLinks(thisRepo, export)
+
+	localIssues := make(map[int]Issue, len(export))
+	for _, iss := range export {
+		localIssues[iss.Number] = iss
+	}
+
+	// Post processing loop over issues: rewrite links to other issues we're
+	// exporting (in this repo or one of baseCfg.ExtraRepositories) into
+	// local links, and collect backlinks along the way.
+	backlinks := map[int][]Issue{}
 	for i := 0; i < len(export); i++ {
 		doc, err := goquery.NewDocumentFromReader(strings.NewReader(export[i].Content))
 		if err == nil {
-			// Replace links that point to other intra-repo issues with intro-blog links.
-			doc.Find("body a").Each(func(index int, item *goquery.Selection) {
-				link, ok := item.Attr("href")
-				if ok {
-					issueNumStr := strings.TrimPrefix(link, thisRepoURL)
-					if issueNumStr != link {
-						// The link links to another issue. Let's extract the number
-						issueNumStr = strings.Trim(issueNumStr, " /")
-						issueNum, err := strconv.Atoi(issueNumStr)
-						if err == nil {
-							// Now that we have the issue number. Find the intra-blog link and replace the link.
-							for _, iss := range export {
-								if iss.Number == issueNum {
-									item.SetAttr("href", iss.Link)
-									break
-								}
-							}
-						} // We just ignore errors and don't change the links in those cases.
-					}
-				}
-			})
+			rewriteCrossRepoLinks(doc, thisRepo, extraRepos, localIssues, export[i], backlinks)
 
 			// Replace the old HTML document with the edited one.
 			nhtml, err := doc.Html()
@@ -142,17 +117,39 @@ func prepareIssues(issues []*github.Issue, baseCfg BaseConfig) ([]Issue, error)
 			}
 		}
 	}
+	for i := range export {
+		export[i].Backlinks = backlinks[export[i].Number]
+	}
+
+	templates, err := loadIssueTemplates(baseCfg)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return export, nil
+	return export, templates, nil
 }
 
 func BuildSite(issues []*github.Issue, baseCfg BaseConfig, themeCfg ThemeConfig) error {
+	renderer := newRenderer(baseCfg, themeCfg)
+
 	// Prepare issues for exporting.
-	exIssues, err := prepareIssues(issues, baseCfg)
+	exIssues, issueTemplates, err := prepareIssues(issues, baseCfg, renderer)
 	if err != nil {
 		return err
 	}
+	baseCfg.Site.IssueTemplates = issueTemplates
+
+	if err := writeHighlightCSS(renderer, outDir); err != nil {
+		return err
+	}
 
+	return buildFromIssues(exIssues, baseCfg, themeCfg)
+}
+
+// buildFromIssues renders every page for an already-prepared set of issues.
+// It is shared by BuildSite and the `glyph serve` dev server, which mixes in
+// synthetic draft issues before calling it.
+func buildFromIssues(exIssues []Issue, baseCfg BaseConfig, themeCfg ThemeConfig) error {
 	// Export issue single pages.
 	for _, exis := range exIssues {
 		err := exportIssue(exis, baseCfg, themeCfg)
@@ -161,8 +158,9 @@ func BuildSite(issues []*github.Issue, baseCfg BaseConfig, themeCfg ThemeConfig)
 		}
 	}
 
-	// Export Atom feed.
-	err = exportFeed(exIssues)
+	// Export the site-wide feed(s).
+	feedExporter := newFeedExporter(baseCfg)
+	err := feedExporter.export(exIssues, "")
 	if err != nil {
 		return err
 	}
@@ -204,8 +202,11 @@ func exportLabelIndexes(template Template, issues []Issue, baseCfg BaseConfig, t
 	}
 
 	// Now export all issues by name.
+	feedExporter := newFeedExporter(baseCfg)
 	for l, lIssues := range issuesByLabel {
+		labelBase := strings.TrimSuffix(labels[l].Link, ".html") + "."
 		data["SelectedLabel"] = labels[l]
+		data["Feeds"] = feedExporter.links(labelBase)
 		tmpl := Template{
 			Source: template.Source,
 			Layout: template.Layout,
@@ -215,12 +216,29 @@ func exportLabelIndexes(template Template, issues []Issue, baseCfg BaseConfig, t
 		if err != nil {
 			return err
 		}
+
+		if err := feedExporter.export(lIssues, labelBase); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 func exportTemplate(data map[string]interface{}, template Template, issues []Issue, baseCfg BaseConfig, themeCfg ThemeConfig) error {
+	tmpl, err := renderTemplate(data, template, issues, baseCfg, themeCfg)
+	if err != nil {
+		return err
+	}
+
+	outname := filepath.Join(outDir, template.Target)
+	return ioutil.WriteFile(outname, tmpl, 0755)
+}
+
+// renderTemplate renders template to bytes without writing it to disk, so
+// callers that want to compare against a previous build (see manifest.go)
+// can decide whether the write is even necessary.
+func renderTemplate(data map[string]interface{}, template Template, issues []Issue, baseCfg BaseConfig, themeCfg ThemeConfig) ([]byte, error) {
 	if data == nil {
 		data = map[string]interface{}{}
 	}
@@ -230,6 +248,9 @@ func exportTemplate(data map[string]interface{}, template Template, issues []Iss
 	data["Issues"] = issues
 	data["Custom"] = baseCfg.Custom
 	data["Theme"] = themeCfg
+	if _, ok := data["Feeds"]; !ok {
+		data["Feeds"] = newFeedExporter(baseCfg).links("")
+	}
 
 	var tmpl string
 	var err error
@@ -239,11 +260,10 @@ func exportTemplate(data map[string]interface{}, template Template, issues []Iss
 		tmpl, err = mustache.RenderFileInLayout(filepath.Join(themeDir, template.Source), filepath.Join(themeDir, template.Layout), data)
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	outname := filepath.Join(outDir, template.Target)
-	return ioutil.WriteFile(outname, []byte(tmpl), 0755)
+	return []byte(tmpl), nil
 }
 
 func exportIssue(issue Issue, baseCfg BaseConfig, themeCfg ThemeConfig) error {
@@ -254,6 +274,7 @@ func exportIssue(issue Issue, baseCfg BaseConfig, themeCfg ThemeConfig) error {
 		"Issue":      issue,
 		"Custom":     baseCfg.Custom,
 		"Theme":      themeCfg,
+		"Feeds":      newFeedExporter(baseCfg).links(""),
 	}
 	issueTmpl, err := mustache.RenderFileInLayout(filepath.Join(themeDir, "issue.mustache"), filepath.Join(themeDir, "layout.mustache"), data)
 	if err != nil {
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/feeds"
+)
+
+// FeedLink is a single <link rel="alternate"> entry, exposed to themes as
+// Site/Issue data so layouts can advertise every enabled feed format.
+type FeedLink struct {
+	Type string
+	Href string
+}
+
+var feedMimeTypes = map[string]string{
+	"atom": "application/atom+xml",
+	"rss":  "application/rss+xml",
+	"json": "application/feed+json",
+}
+
+// FeedExporter writes the issues for a site, or for a single label, out in
+// every format enabled by baseCfg.Site.Feeds.
+type FeedExporter struct {
+	baseCfg BaseConfig
+}
+
+func newFeedExporter(baseCfg BaseConfig) FeedExporter {
+	return FeedExporter{baseCfg: baseCfg}
+}
+
+// enabledFormats defaults to Atom alone, matching glyph's original feed
+// output, when the site doesn't opt into the others.
+func (fe FeedExporter) enabledFormats() []string {
+	if len(fe.baseCfg.Site.Feeds) == 0 {
+		return []string{"atom"}
+	}
+	return fe.baseCfg.Site.Feeds
+}
+
+// links returns the <link rel="alternate"> entries for baseName ("" for the
+// site-wide feed, or a label base such as "label-go." for a per-label feed).
+func (fe FeedExporter) links(baseName string) []FeedLink {
+	links := []FeedLink{}
+	for _, format := range fe.enabledFormats() {
+		links = append(links, FeedLink{Type: feedMimeTypes[format], Href: baseName + feedFilename(format)})
+	}
+	return links
+}
+
+// export writes issues to outDir in every enabled format, using baseName as
+// the filename prefix ("" for the site-wide feed, "label-go." for that
+// label's feed).
+func (fe FeedExporter) export(issues []Issue, baseName string) error {
+	for _, format := range fe.enabledFormats() {
+		body, err := fe.render(issues, format)
+		if err != nil {
+			return err
+		}
+
+		outname := filepath.Join(outDir, baseName+feedFilename(format))
+		if err := ioutil.WriteFile(outname, body, 0755); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func feedFilename(format string) string {
+	switch format {
+	case "rss":
+		return "rss.xml"
+	case "json":
+		return "feed.json"
+	default:
+		return "atom.xml"
+	}
+}
+
+func (fe FeedExporter) render(issues []Issue, format string) ([]byte, error) {
+	if format == "json" {
+		return fe.renderJSONFeed(issues)
+	}
+
+	feed := fe.buildFeed(issues)
+	var (
+		body string
+		err  error
+	)
+	if format == "rss" {
+		body, err = feed.ToRss()
+	} else {
+		body, err = feed.ToAtom()
+	}
+	return []byte(body), err
+}
+
+func (fe FeedExporter) buildFeed(issues []Issue) *feeds.Feed {
+	baseCfg := fe.baseCfg
+	feed := &feeds.Feed{
+		Title:       baseCfg.Site.Title,
+		Link:        &feeds.Link{Href: fmt.Sprintf("https://%s.github.io/%s", baseCfg.Repository.Users[0], baseCfg.Repository.Name)},
+		Description: baseCfg.Site.OneLineDesc,
+		Author:      &feeds.Author{Name: baseCfg.Site.Author, Email: baseCfg.Site.Mail},
+		Created:     time.Now(),
+	}
+	feed.Items = []*feeds.Item{}
+
+	for _, issue := range issues {
+		feed.Items = append(feed.Items, &feeds.Item{
+			Title:       issue.Title,
+			Link:        &feeds.Link{Href: fe.issueURL(issue)},
+			Description: issue.Summary,
+			Author:      &feeds.Author{Name: baseCfg.Site.Author, Email: baseCfg.Site.Mail},
+			Created:     issue.Created,
+		})
+	}
+
+	return feed
+}
+
+func (fe FeedExporter) issueURL(issue Issue) string {
+	return fmt.Sprintf("https://%s.github.io/%s/%s", fe.baseCfg.Repository.Users[0], fe.baseCfg.Repository.Name, issue.Link)
+}
+
+// jsonFeedDoc is a JSON Feed 1.1 document (https://www.jsonfeed.org/version/1.1/).
+type jsonFeedDoc struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Author      jsonFeedAuthor `json:"author"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	ContentHTML   string   `json:"content_html,omitempty"`
+	Summary       string   `json:"summary,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	DatePublished string   `json:"date_published,omitempty"`
+	DateModified  string   `json:"date_modified,omitempty"`
+}
+
+func (fe FeedExporter) renderJSONFeed(issues []Issue) ([]byte, error) {
+	baseCfg := fe.baseCfg
+	doc := jsonFeedDoc{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       baseCfg.Site.Title,
+		HomePageURL: fmt.Sprintf("https://%s.github.io/%s", baseCfg.Repository.Users[0], baseCfg.Repository.Name),
+		Description: baseCfg.Site.OneLineDesc,
+		Author:      jsonFeedAuthor{Name: baseCfg.Site.Author},
+		Items:       make([]jsonFeedItem, 0, len(issues)),
+	}
+
+	for _, issue := range issues {
+		tags := make([]string, 0, len(issue.Labels))
+		for _, l := range issue.Labels {
+			tags = append(tags, l.Name)
+		}
+
+		updated := issue.Updated
+		if updated.IsZero() {
+			updated = issue.Created
+		}
+
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:            fe.issueURL(issue),
+			URL:           fe.issueURL(issue),
+			Title:         issue.Title,
+			ContentHTML:   issue.Content,
+			Summary:       issue.Summary,
+			Tags:          tags,
+			DatePublished: issue.Created.Format(time.RFC3339),
+			DateModified:  updated.Format(time.RFC3339),
+		})
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
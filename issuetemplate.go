@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// issueTemplateDirs are checked in order; the first one that exists wins,
+// mirroring GitHub's own lookup for .github/ISSUE_TEMPLATE.
+var issueTemplateDirs = []string{
+	filepath.Join(".glyph", "ISSUE_TEMPLATE"),
+	filepath.Join(".github", "ISSUE_TEMPLATE"),
+}
+
+// IssueTemplate is the parsed front matter of a GitHub-style issue template,
+// exposed to themes as Site.IssueTemplates so a "New post" page can link
+// readers to a prefilled new-issue form.
+type IssueTemplate struct {
+	Name        string
+	About       string
+	Title       string
+	Labels      []string
+	Assignees   []string
+	NewIssueURL string
+}
+
+type issueTemplateFrontMatter struct {
+	Name      string   `yaml:"name"`
+	About     string   `yaml:"about"`
+	Title     string   `yaml:"title"`
+	Labels    []string `yaml:"labels"`
+	Assignees []string `yaml:"assignees"`
+}
+
+// loadIssueTemplates reads every *.md file from the first existing issue
+// template directory and builds the prefilled GitHub new-issue URL for each.
+// Label names not present in baseCfg.Repository.Labels are dropped rather
+// than rejected outright, since a stale template shouldn't break the build.
+// It returns a nil slice, not an error, when the repo ships no templates at
+// all.
+func loadIssueTemplates(baseCfg BaseConfig) ([]IssueTemplate, error) {
+	knownLabels := make(map[string]bool, len(baseCfg.Repository.Labels))
+	for _, l := range baseCfg.Repository.Labels {
+		knownLabels[l] = true
+	}
+
+	dir := ""
+	for _, candidate := range issueTemplateDirs {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			dir = candidate
+			break
+		}
+	}
+	if dir == "" {
+		return nil, nil
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	newIssueBase := fmt.Sprintf("https://github.com/%s/%s/issues/new", baseCfg.Repository.Users[0], baseCfg.Repository.Name)
+
+	templates := []IssueTemplate{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
+			continue
+		}
+
+		raw, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		meta, _, ok := splitFrontMatter(raw)
+		if !ok {
+			continue // a stray Markdown file without front matter isn't a template
+		}
+
+		fm := issueTemplateFrontMatter{}
+		if err := yaml.Unmarshal(meta, &fm); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if fm.Name == "" {
+			fm.Name = strings.TrimSuffix(entry.Name(), ".md")
+		}
+
+		var labels []string
+		for _, l := range fm.Labels {
+			if knownLabels[l] {
+				labels = append(labels, l)
+			}
+		}
+
+		q := url.Values{}
+		if fm.Title != "" {
+			q.Set("title", fm.Title)
+		}
+		if len(labels) > 0 {
+			q.Set("labels", strings.Join(labels, ","))
+		}
+		if len(fm.Assignees) > 0 {
+			q.Set("assignees", strings.Join(fm.Assignees, ","))
+		}
+
+		templates = append(templates, IssueTemplate{
+			Name:        fm.Name,
+			About:       fm.About,
+			Title:       fm.Title,
+			Labels:      labels,
+			Assignees:   fm.Assignees,
+			NewIssueURL: newIssueBase + "?" + q.Encode(),
+		})
+	}
+
+	return templates, nil
+}
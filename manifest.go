@@ -0,0 +1,311 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// manifestFilename is where BuildSiteIncremental persists its content-hash
+// manifest, inside outDir so it travels with the generated site.
+const manifestFilename = ".glyph-manifest.json"
+
+// buildManifest is the incremental-build state written to
+// outDir/.glyph-manifest.json. It lets BuildSiteIncremental skip re-running
+// exportIssue for issues that haven't changed, skip regenerating label
+// indexes whose member set is the same, and skip rewriting any other output
+// file whose rendered bytes are unchanged.
+type buildManifest struct {
+	ConfigHash string            `json:"config_hash"`
+	ThemeHash  string            `json:"theme_hash"`
+	Issues     map[string]string `json:"issues"` // issue number -> content hash
+	Labels     map[string]string `json:"labels"` // label name -> member-set hash
+	Files      map[string]string `json:"files"`  // output path -> rendered-bytes hash
+}
+
+func newBuildManifest() *buildManifest {
+	return &buildManifest{
+		Issues: map[string]string{},
+		Labels: map[string]string{},
+		Files:  map[string]string{},
+	}
+}
+
+// loadManifest reads a manifest from path, falling back to an empty one if
+// it's missing or unreadable -- a missing manifest just means a full build.
+func loadManifest(path string) *buildManifest {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newBuildManifest()
+	}
+
+	m := newBuildManifest()
+	if err := json.Unmarshal(raw, m); err != nil {
+		return newBuildManifest()
+	}
+	if m.Issues == nil {
+		m.Issues = map[string]string{}
+	}
+	if m.Labels == nil {
+		m.Labels = map[string]string{}
+	}
+	if m.Files == nil {
+		m.Files = map[string]string{}
+	}
+	return m
+}
+
+func (m *buildManifest) save(path string) error {
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, raw, 0644)
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// issueContentHash hashes the fields that can change an issue's rendered
+// output: title, body, labels, GitHub's updated_at, and the set of issues
+// that back-link to it -- a new backlink doesn't touch any of this issue's
+// own GitHub fields, but its "Referenced by" section still needs to
+// regenerate.
+func issueContentHash(issue Issue) string {
+	labelNames := make([]string, len(issue.Labels))
+	for i, l := range issue.Labels {
+		labelNames[i] = l.Name
+	}
+	sort.Strings(labelNames)
+
+	backlinkNums := make([]string, len(issue.Backlinks))
+	for i, b := range issue.Backlinks {
+		backlinkNums[i] = fmt.Sprintf("%d", b.Number)
+	}
+	sort.Strings(backlinkNums)
+
+	key := strings.Join([]string{
+		issue.Title,
+		issue.Content,
+		strings.Join(labelNames, ","),
+		issue.Updated.Format(time.RFC3339),
+		strings.Join(backlinkNums, ","),
+	}, "\x00")
+	return hashBytes([]byte(key))
+}
+
+// labelMembersHash hashes each member issue's number together with its
+// already-computed content hash (issueHashes, keyed the same way as
+// buildManifest.Issues), so a label index regenerates both when its
+// membership changes and when a member's title/body/labels change --
+// hashing the member-number set alone would leave a label index showing a
+// renamed issue's old title after an incremental build skips that issue's
+// own page. Reusing issueHashes avoids re-hashing every member's full
+// rendered content once per label it belongs to.
+func labelMembersHash(issues []Issue, issueHashes map[string]string) string {
+	entries := make([]string, len(issues))
+	for i, issue := range issues {
+		key := fmt.Sprintf("%d", issue.Number)
+		entries[i] = key + ":" + issueHashes[key]
+	}
+	sort.Strings(entries)
+	return hashBytes([]byte(strings.Join(entries, ",")))
+}
+
+// configThemeHash hashes baseCfg and themeCfg -- plus the contents of every
+// file under themeDir -- so an incremental build falls back to a full
+// rebuild whenever the config, the ThemeConfig struct itself, or any
+// template/partial/asset file changes. Hashing ThemeConfig alone would miss
+// edits to the mustache files it merely points at. RepositoryConfig.Issues
+// is excluded from the hashed config: it's already-fetched GitHub content
+// for cross-repo link resolution, not config, and hashing it wholesale
+// would force a full rebuild here whenever an unrelated extra repo's issue
+// body changes, even though nothing it produces actually changes.
+func configThemeHash(baseCfg BaseConfig, themeCfg ThemeConfig) (string, string, error) {
+	baseCfg.Repository.Issues = nil
+	extraRepos := make([]RepositoryConfig, len(baseCfg.ExtraRepositories))
+	for i, r := range baseCfg.ExtraRepositories {
+		r.Issues = nil
+		extraRepos[i] = r
+	}
+	baseCfg.ExtraRepositories = extraRepos
+
+	cfgBytes, _ := json.Marshal(baseCfg)
+	themeBytes, _ := json.Marshal(themeCfg)
+
+	filesHash, err := themeFilesHash(themeDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hashBytes(cfgBytes), hashBytes(append(themeBytes, []byte(filesHash)...)), nil
+}
+
+// themeFilesHash fingerprints every file under dir by path, size, and mtime,
+// in filepath.Walk's deterministic lexical order. A size+mtime quick check
+// (the same trick rsync uses) avoids reading every theme asset's full bytes
+// on every incremental build, which would otherwise make change-detection
+// itself scale with theme size rather than with what changed.
+func themeFilesHash(dir string) (string, error) {
+	hasher := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// filepath.Walk doesn't follow symlinks -- skip them rather than
+		// erroring when a symlinked partials directory shows up as a
+		// non-directory entry.
+		if info.Mode()&os.ModeSymlink != 0 || info.IsDir() {
+			return nil
+		}
+
+		fmt.Fprintf(hasher, "%s\t%d\t%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// BuildSiteIncremental is BuildSite's incremental sibling: it persists a
+// content-hash manifest in outDir and, on later runs, only re-renders what
+// changed since the last build -- for a blog with hundreds of issues this
+// cuts rebuild time from O(n) to O(changed). Pass force=true (glyph's
+// --force flag) to ignore the manifest and do a full rebuild.
+func BuildSiteIncremental(issues []*github.Issue, baseCfg BaseConfig, themeCfg ThemeConfig, force bool) error {
+	manifestPath := filepath.Join(outDir, manifestFilename)
+	configHash, themeHash, err := configThemeHash(baseCfg, themeCfg)
+	if err != nil {
+		return err
+	}
+
+	manifest := loadManifest(manifestPath)
+	if force || manifest.ConfigHash != configHash || manifest.ThemeHash != themeHash {
+		manifest = newBuildManifest()
+	}
+	manifest.ConfigHash = configHash
+	manifest.ThemeHash = themeHash
+
+	renderer := newRenderer(baseCfg, themeCfg)
+	exIssues, issueTemplates, err := prepareIssues(issues, baseCfg, renderer)
+	if err != nil {
+		return err
+	}
+	baseCfg.Site.IssueTemplates = issueTemplates
+
+	if err := writeHighlightCSS(renderer, outDir); err != nil {
+		return err
+	}
+
+	// Export issue single pages, skipping any whose content hash matches the
+	// last build.
+	newIssueHashes := map[string]string{}
+	for _, exis := range exIssues {
+		key := fmt.Sprintf("%d", exis.Number)
+		hash := issueContentHash(exis)
+		newIssueHashes[key] = hash
+
+		if !force && manifest.Issues[key] == hash {
+			continue
+		}
+		if err := exportIssue(exis, baseCfg, themeCfg); err != nil {
+			return err
+		}
+	}
+	manifest.Issues = newIssueHashes
+
+	feedExporter := newFeedExporter(baseCfg)
+	if err := feedExporter.export(exIssues, ""); err != nil {
+		return err
+	}
+
+	if err := writeTemplateIfChanged(manifest, nil, themeCfg.IndexTemplate, exIssues, baseCfg, themeCfg, force); err != nil {
+		return err
+	}
+
+	if err := exportLabelIndexesIncremental(themeCfg.IndexTemplate, exIssues, baseCfg, themeCfg, manifest, newIssueHashes, force); err != nil {
+		return err
+	}
+
+	for _, tmpl := range themeCfg.OtherTemplates {
+		if err := writeTemplateIfChanged(manifest, nil, tmpl, exIssues, baseCfg, themeCfg, force); err != nil {
+			return err
+		}
+	}
+
+	return manifest.save(manifestPath)
+}
+
+// writeTemplateIfChanged renders template and only rewrites its output file
+// when the rendered bytes differ from the last build, preserving the file's
+// mtime otherwise so rsync/CDN uploads stay fast.
+func writeTemplateIfChanged(manifest *buildManifest, data map[string]interface{}, template Template, issues []Issue, baseCfg BaseConfig, themeCfg ThemeConfig, force bool) error {
+	rendered, err := renderTemplate(data, template, issues, baseCfg, themeCfg)
+	if err != nil {
+		return err
+	}
+
+	outname := filepath.Join(outDir, template.Target)
+	hash := hashBytes(rendered)
+	if !force && manifest.Files[outname] == hash {
+		return nil
+	}
+	manifest.Files[outname] = hash
+
+	return ioutil.WriteFile(outname, rendered, 0755)
+}
+
+// exportLabelIndexesIncremental regenerates a label's index page and feed
+// only when its member set or a member's content changed since the last
+// build. issueHashes is the per-issue content hash the caller already
+// computed this build (see BuildSiteIncremental), reused here instead of
+// re-hashing each member's content once per label it belongs to.
+func exportLabelIndexesIncremental(template Template, issues []Issue, baseCfg BaseConfig, themeCfg ThemeConfig, manifest *buildManifest, issueHashes map[string]string, force bool) error {
+	labels := map[string]Label{}
+	issuesByLabel := map[string][]Issue{}
+	for _, issue := range issues {
+		for _, l := range issue.Labels {
+			labels[l.Name] = l
+			issuesByLabel[l.Name] = append(issuesByLabel[l.Name], issue)
+		}
+	}
+
+	feedExporter := newFeedExporter(baseCfg)
+	newLabelHashes := map[string]string{}
+	for name, lIssues := range issuesByLabel {
+		hash := labelMembersHash(lIssues, issueHashes)
+		newLabelHashes[name] = hash
+		if !force && manifest.Labels[name] == hash {
+			continue
+		}
+
+		labelBase := strings.TrimSuffix(labels[name].Link, ".html") + "."
+		data := map[string]interface{}{
+			"SelectedLabel": labels[name],
+			"Feeds":         feedExporter.links(labelBase),
+		}
+		tmpl := Template{Source: template.Source, Layout: template.Layout, Target: labels[name].Link}
+		if err := exportTemplate(data, tmpl, lIssues, baseCfg, themeCfg); err != nil {
+			return err
+		}
+		if err := feedExporter.export(lIssues, labelBase); err != nil {
+			return err
+		}
+	}
+	manifest.Labels = newLabelHashes
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/gosimple/slug"
+	gfm "github.com/shurcooL/github_flavored_markdown"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Renderer turns issue body Markdown into HTML. glyph ships two
+// implementations, selected via BaseConfig.Renderer ("gfm" or "goldmark").
+type Renderer interface {
+	Render(src []byte) ([]byte, error)
+	Name() string
+}
+
+// newRenderer selects a Renderer for baseCfg.Renderer, defaulting to "gfm"
+// so existing configs keep rendering exactly as before.
+func newRenderer(baseCfg BaseConfig, themeCfg ThemeConfig) Renderer {
+	switch baseCfg.Renderer {
+	case "goldmark":
+		return newGoldmarkRenderer(themeCfg)
+	default:
+		return gfmRenderer{}
+	}
+}
+
+// gfmRenderer wraps the original shurcooL/github_flavored_markdown renderer.
+type gfmRenderer struct{}
+
+func (gfmRenderer) Render(src []byte) ([]byte, error) { return gfm.Markdown(src), nil }
+func (gfmRenderer) Name() string                      { return "gfm" }
+
+// goldmarkRenderer renders GFM-flavored Markdown with goldmark, highlighting
+// fenced code blocks with Chroma and anchoring headings using the same slug
+// logic as Issue.Link.
+type goldmarkRenderer struct {
+	md          goldmark.Markdown
+	chromaStyle string
+}
+
+func newGoldmarkRenderer(themeCfg ThemeConfig) *goldmarkRenderer {
+	style := themeCfg.ChromaStyle
+	if style == "" {
+		style = "github"
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			extension.Footnote,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle(style),
+				highlighting.WithFormatOptions(html.WithClasses(true)),
+			),
+		),
+		goldmark.WithRendererOptions(
+			gmhtml.WithUnsafe(),
+		),
+	)
+
+	return &goldmarkRenderer{md: md, chromaStyle: style}
+}
+
+func (r *goldmarkRenderer) Render(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.md.Convert(src, &buf); err != nil {
+		return nil, err
+	}
+
+	// Anchor headings with the same slug logic Issue.Link uses, so themes
+	// can deep-link into a post's table of contents.
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return buf.Bytes(), nil
+	}
+	doc.Find("h1, h2, h3, h4, h5, h6").Each(func(_ int, h *goquery.Selection) {
+		if _, exists := h.Attr("id"); !exists {
+			h.SetAttr("id", slug.Make(h.Text()))
+		}
+	})
+	anchored, err := doc.Find("body").Html()
+	if err != nil {
+		return buf.Bytes(), nil
+	}
+
+	return []byte(anchored), nil
+}
+
+func (r *goldmarkRenderer) Name() string { return "goldmark" }
+
+// writeHighlightCSS emits outDir/highlight.css for the goldmark renderer's
+// Chroma style when that style requires an external stylesheet. It is a
+// no-op for any other Renderer.
+func writeHighlightCSS(renderer Renderer, outDir string) error {
+	gr, ok := renderer.(*goldmarkRenderer)
+	if !ok {
+		return nil
+	}
+
+	style := styles.Get(gr.chromaStyle)
+	if style == nil {
+		style = styles.Fallback
+	}
+
+	formatter := html.New(html.WithClasses(true))
+	var buf bytes.Buffer
+	if err := formatter.WriteCSS(&buf, style); err != nil {
+		return fmt.Errorf("rendering highlight.css: %w", err)
+	}
+
+	return ioutil.WriteFile(filepath.Join(outDir, "highlight.css"), buf.Bytes(), 0755)
+}
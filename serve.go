@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/google/go-github/github"
+)
+
+const (
+	// draftsDir is the local directory of Markdown drafts that `glyph serve`
+	// mixes into the preview alongside the real GitHub issues.
+	draftsDir = "drafts"
+
+	// debounceWait coalesces bursts of filesystem events (an editor often
+	// fires several writes per save) into a single rebuild.
+	debounceWait = 200 * time.Millisecond
+
+	reloadScript = `<script>new EventSource("/__glyph/reload").onmessage=function(){location.reload()}</script>`
+)
+
+// ServeSite runs a local preview server. It rebuilds the site into a temp
+// directory, serves it over HTTP with a live-reload script injected into
+// every page, and watches the theme dir, the config file, and drafts/ for
+// changes, rebuilding (debounced) and pushing a reload to connected browsers
+// whenever something changes. It blocks until it receives SIGINT or SIGTERM.
+func ServeSite(issues []*github.Issue, baseCfg BaseConfig, themeCfg ThemeConfig, configPath string, port int) error {
+	tmpOut, err := ioutil.TempDir("", "glyph-serve-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpOut)
+
+	origOutDir := outDir
+	outDir = tmpOut
+	defer func() { outDir = origOutDir }()
+
+	hub := newReloadHub()
+	rebuild := func() {
+		renderer := newRenderer(baseCfg, themeCfg)
+
+		exIssues, issueTemplates, err := prepareIssues(issues, baseCfg, renderer)
+		if err != nil {
+			log.Printf("glyph serve: prepareIssues: %v", err)
+			return
+		}
+		baseCfg.Site.IssueTemplates = issueTemplates
+
+		drafts, err := loadDraftIssues(draftsDir, renderer)
+		if err != nil {
+			log.Printf("glyph serve: loadDraftIssues: %v", err)
+			return
+		}
+		exIssues = append(exIssues, drafts...)
+
+		if err := writeHighlightCSS(renderer, outDir); err != nil {
+			log.Printf("glyph serve: highlight.css: %v", err)
+			return
+		}
+
+		if err := buildFromIssues(exIssues, baseCfg, themeCfg); err != nil {
+			log.Printf("glyph serve: build: %v", err)
+			return
+		}
+
+		log.Printf("glyph serve: rebuilt (%d issues, %d drafts)", len(exIssues)-len(drafts), len(drafts))
+		hub.broadcast()
+	}
+	rebuild()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watchRecursive(watcher, themeDir); err != nil {
+		log.Printf("glyph serve: not watching %s: %v", themeDir, err)
+	}
+	for _, path := range []string{draftsDir, configPath} {
+		if err := watcher.Add(path); err != nil {
+			log.Printf("glyph serve: not watching %s: %v", path, err)
+		}
+	}
+	go debounceRebuild(watcher, rebuild)
+
+	mux := http.NewServeMux()
+	mux.Handle("/__glyph/reload", hub)
+	mux.Handle("/", injectingFileServer{root: http.FileServer(http.Dir(tmpOut))})
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigc
+		log.Printf("glyph serve: shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	log.Printf("glyph serve: listening on http://localhost:%d (drafts in %s/)", port, draftsDir)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// watchRecursive adds dir and every subdirectory beneath it to watcher.
+// fsnotify only watches a single directory level, and theme assets are
+// routinely nested in partials/, assets/, etc., so a plain watcher.Add(dir)
+// misses changes there.
+func watchRecursive(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// debounceRebuild reads watcher events until it is closed, calling rebuild
+// after debounceWait of quiet following the first event in a burst.
+func debounceRebuild(watcher *fsnotify.Watcher, rebuild func()) {
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// A newly created subdirectory (e.g. a new theme partials/
+			// folder) needs to be watched explicitly, since fsnotify
+			// doesn't watch recursively on its own.
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watcher.Add(event.Name); err != nil {
+						log.Printf("glyph serve: not watching %s: %v", event.Name, err)
+					}
+				}
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(debounceWait, rebuild)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("glyph serve: watcher: %v", err)
+		}
+	}
+}
+
+// reloadHub fans out a reload notification to every connected browser tab
+// over Server-Sent Events.
+type reloadHub struct {
+	mu   sync.Mutex
+	subs map[chan struct{}]bool
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{subs: map[chan struct{}]bool{}}
+}
+
+func (h *reloadHub) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	h.subs[ch] = true
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *reloadHub) unsubscribe(ch chan struct{}) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+func (h *reloadHub) broadcast() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *reloadHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := h.subscribe()
+	defer h.unsubscribe(ch)
+
+	for {
+		select {
+		case <-ch:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// injectingFileServer wraps a file server, inlining the live-reload script
+// into HTML responses just before </body>.
+type injectingFileServer struct {
+	root http.Handler
+}
+
+func (fs injectingFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, ".html") && !strings.HasSuffix(r.URL.Path, "/") {
+		fs.root.ServeHTTP(w, r)
+		return
+	}
+
+	// Buffer the whole response -- headers, status, and body -- so we can
+	// grow the body with the reload script and fix up Content-Length before
+	// any of it reaches the real ResponseWriter. Writing the grown body
+	// after net/http has already committed the original (smaller)
+	// Content-Length would get it silently truncated by net/http.
+	rec := newBufferingResponseWriter()
+	fs.root.ServeHTTP(rec, r)
+
+	body := rec.buf.Bytes()
+	if bytes.Contains(body, []byte("</body>")) {
+		body = bytes.Replace(body, []byte("</body>"), []byte(reloadScript+"</body>"), 1)
+	}
+
+	for key, values := range rec.header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(rec.statusCode)
+	w.Write(body)
+}
+
+// bufferingResponseWriter captures the whole response -- headers, status
+// code, and body -- instead of passing writes through, so injectingFileServer
+// can rewrite the body and fix up Content-Length before committing anything
+// to the real ResponseWriter.
+type bufferingResponseWriter struct {
+	header     http.Header
+	statusCode int
+	buf        bytes.Buffer
+}
+
+func newBufferingResponseWriter() *bufferingResponseWriter {
+	return &bufferingResponseWriter{header: http.Header{}, statusCode: http.StatusOK}
+}
+
+func (bw *bufferingResponseWriter) Header() http.Header { return bw.header }
+
+func (bw *bufferingResponseWriter) WriteHeader(status int) { bw.statusCode = status }
+
+func (bw *bufferingResponseWriter) Write(p []byte) (int, error) { return bw.buf.Write(p) }